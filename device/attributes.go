@@ -0,0 +1,84 @@
+package device
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+)
+
+// attributeSignature returns a stable short hash identifying a set of
+// device attributes. Nomad assumes every device in a DeviceGroup shares the
+// same attributes, so devices whose attributes differ must be split into
+// distinct groups; this signature is what tells two otherwise-identical
+// devices apart.
+func attributeSignature(attrs map[string]attributeConfig) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		attr := attrs[name]
+		fmt.Fprintf(h, "%s=", name)
+		switch {
+		case attr.Int != nil:
+			fmt.Fprintf(h, "int:%d", *attr.Int)
+		case attr.Float != nil:
+			fmt.Fprintf(h, "float:%f", *attr.Float)
+		case attr.String != nil:
+			fmt.Fprintf(h, "string:%s", *attr.String)
+		case attr.Bool != nil:
+			fmt.Fprintf(h, "bool:%t", *attr.Bool)
+		}
+		fmt.Fprintf(h, ";unit:%s;", attr.Unit)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+// deviceGroupIdentity returns the device group key and name a device
+// belongs to: Vendor/Type/Model plus its attribute signature, since Nomad
+// assumes every device in a group shares the same attributes. Fingerprint
+// and stats collection both use this so DeviceGroupStats line up with the
+// DeviceGroups Nomad actually sees.
+func deviceGroupIdentity(dev GenericDevice) (key, name string) {
+	sig := attributeSignature(dev.Attributes)
+
+	key = fmt.Sprintf("%s/%s/%s/%s", dev.Vendor, dev.Type, dev.Model, sig)
+
+	name = dev.Model
+	if sig != "" {
+		name = fmt.Sprintf("%s-%s", dev.Model, sig)
+	}
+
+	return key, name
+}
+
+// toStructsAttributes converts the plugin's typed attribute config into the
+// structs.Attribute values Nomad's DeviceGroup expects.
+func toStructsAttributes(attrs map[string]attributeConfig) map[string]*structs.Attribute {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	out := make(map[string]*structs.Attribute, len(attrs))
+	for name, attr := range attrs {
+		out[name] = &structs.Attribute{
+			Int:    attr.Int,
+			Float:  attr.Float,
+			String: attr.String,
+			Bool:   attr.Bool,
+			Unit:   attr.Unit,
+		}
+	}
+	return out
+}
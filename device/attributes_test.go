@@ -0,0 +1,104 @@
+package device
+
+import "testing"
+
+func int64ptr(v int64) *int64 { return &v }
+
+func TestAttributeSignatureStable(t *testing.T) {
+	attrs := map[string]attributeConfig{
+		"memory": {Int: int64ptr(16), Unit: "GiB"},
+	}
+
+	first := attributeSignature(attrs)
+	second := attributeSignature(attrs)
+
+	if first != second {
+		t.Fatalf("expected stable signature, got %q then %q", first, second)
+	}
+	if first == "" {
+		t.Fatal("expected non-empty signature for non-empty attributes")
+	}
+}
+
+func TestAttributeSignatureEmpty(t *testing.T) {
+	if sig := attributeSignature(nil); sig != "" {
+		t.Fatalf("expected empty signature for nil attributes, got %q", sig)
+	}
+	if sig := attributeSignature(map[string]attributeConfig{}); sig != "" {
+		t.Fatalf("expected empty signature for empty attributes, got %q", sig)
+	}
+}
+
+func TestAttributeSignatureDiffersOnValue(t *testing.T) {
+	a := attributeSignature(map[string]attributeConfig{
+		"memory": {Int: int64ptr(16), Unit: "GiB"},
+	})
+	b := attributeSignature(map[string]attributeConfig{
+		"memory": {Int: int64ptr(32), Unit: "GiB"},
+	})
+
+	if a == b {
+		t.Fatalf("expected different signatures for different attribute values, both were %q", a)
+	}
+}
+
+// TestDeviceGroupIdentitySplitsByAttributes exercises the scenario scheduling
+// constraints/affinities depend on: two devices with the same Model but
+// different Attributes must resolve to different group keys, so Nomad never
+// sees them merged into one DeviceGroup with a single set of attributes.
+func TestDeviceGroupIdentitySplitsByAttributes(t *testing.T) {
+	base := GenericDevice{
+		Vendor: "acme",
+		Type:   "gpu",
+		Model:  "x1000",
+	}
+
+	plain := base
+	plain.Attributes = nil
+
+	big := base
+	big.Attributes = map[string]attributeConfig{"memory": {Int: int64ptr(32), Unit: "GiB"}}
+
+	small := base
+	small.Attributes = map[string]attributeConfig{"memory": {Int: int64ptr(16), Unit: "GiB"}}
+
+	plainKey, plainName := deviceGroupIdentity(plain)
+	bigKey, bigName := deviceGroupIdentity(big)
+	smallKey, smallName := deviceGroupIdentity(small)
+
+	if plainKey == bigKey || plainKey == smallKey || bigKey == smallKey {
+		t.Fatalf("expected distinct group keys, got plain=%q big=%q small=%q", plainKey, bigKey, smallKey)
+	}
+
+	if plainName != base.Model {
+		t.Fatalf("expected unattributed device name to be bare model %q, got %q", base.Model, plainName)
+	}
+	if bigName == smallName {
+		t.Fatalf("expected distinct group names for differing attributes, both were %q", bigName)
+	}
+}
+
+func TestDeviceGroupIdentitySameAttributesSameGroup(t *testing.T) {
+	dev1 := GenericDevice{
+		Vendor:     "acme",
+		Type:       "gpu",
+		Model:      "x1000",
+		Attributes: map[string]attributeConfig{"memory": {Int: int64ptr(16), Unit: "GiB"}},
+	}
+	dev2 := GenericDevice{
+		Vendor:     "acme",
+		Type:       "gpu",
+		Model:      "x1000",
+		Attributes: map[string]attributeConfig{"memory": {Int: int64ptr(16), Unit: "GiB"}},
+	}
+
+	key1, name1 := deviceGroupIdentity(dev1)
+	key2, name2 := deviceGroupIdentity(dev2)
+
+	if key1 != key2 {
+		t.Fatalf("expected identical devices to share a group key, got %q and %q", key1, key2)
+	}
+	if name1 != name2 {
+		t.Fatalf("expected identical devices to share a group name, got %q and %q", name1, name2)
+	}
+}
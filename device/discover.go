@@ -0,0 +1,127 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// discoveryProbeTimeout bounds how long a single discovery command may run
+// before it's killed, so a hung probe script can't block fingerprinting,
+// context cancellation, or plugin shutdown indefinitely.
+const discoveryProbeTimeout = 30 * time.Second
+
+// probeAttributeValue is the JSON representation of a single typed device
+// attribute reported by a discovery probe, mirroring attributeConfig.
+type probeAttributeValue struct {
+	Int    *int64   `json:"int,omitempty"`
+	Float  *float64 `json:"float,omitempty"`
+	String *string  `json:"string,omitempty"`
+	Bool   *bool    `json:"bool,omitempty"`
+	Unit   string   `json:"unit,omitempty"`
+}
+
+// probeDiscoveredDevice is the JSON representation of a single device
+// reported on a discovery probe's stdout.
+type probeDiscoveredDevice struct {
+	Interface       string                         `json:"interface"`
+	Vendor          string                         `json:"vendor"`
+	Model           string                         `json:"model"`
+	Type            string                         `json:"type"`
+	FirmwareVersion string                         `json:"firmware_version,omitempty"`
+	Attributes      map[string]probeAttributeValue `json:"attributes,omitempty"`
+
+	// NumPseudo generates N non-exclusive pseudo-IDs for this single
+	// physical device, for oversubscribed/shared access. Defaults to 1.
+	NumPseudo int `json:"num_pseudo,omitempty"`
+}
+
+// runDiscoveryProbes executes every configured discovery command and merges
+// their reported devices into a single list of fingerprintedDevices.
+func (d *GenericDevicePlugin) runDiscoveryProbes(ctx context.Context) ([]*fingerprintedDevice, error) {
+	var discovered []*fingerprintedDevice
+
+	for _, command := range d.discoveryCommands {
+		devs, err := runDiscoveryProbe(ctx, command)
+		if err != nil {
+			return nil, fmt.Errorf("discovery probe %q: %w", command, err)
+		}
+		discovered = append(discovered, devs...)
+	}
+
+	return discovered, nil
+}
+
+// runDiscoveryProbe executes a single discovery command and maps its JSON
+// stdout into fingerprintedDevices, expanding NumPseudo into that many
+// pseudo-IDs per physical device.
+func runDiscoveryProbe(ctx context.Context, command string) ([]*fingerprintedDevice, error) {
+	ctx, cancel := context.WithTimeout(ctx, discoveryProbeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, stderr.String())
+	}
+
+	var probed []probeDiscoveredDevice
+	if err := json.Unmarshal(stdout.Bytes(), &probed); err != nil {
+		return nil, fmt.Errorf("decoding probe output: %w", err)
+	}
+
+	devices := make([]*fingerprintedDevice, 0, len(probed))
+	for _, p := range probed {
+		numPseudo := p.NumPseudo
+		if numPseudo == 0 {
+			numPseudo = 1
+		}
+
+		attrs := toAttributeConfig(p.Attributes)
+		for pseudoIndex := 0; pseudoIndex < numPseudo; pseudoIndex++ {
+			id := fmt.Sprintf("%s/%s/%s/%s", p.Type, p.Vendor, p.Model, p.Interface)
+			if numPseudo > 1 {
+				id = fmt.Sprintf("%s/%d", id, pseudoIndex)
+			}
+
+			devices = append(devices, &fingerprintedDevice{
+				ID: id,
+				device: GenericDevice{
+					Type:       p.Type,
+					Vendor:     p.Vendor,
+					Model:      p.Model,
+					Attributes: attrs,
+				},
+			})
+		}
+	}
+
+	return devices, nil
+}
+
+// toAttributeConfig converts a discovery probe's reported attributes into
+// the same representation used for statically configured devices.
+func toAttributeConfig(in map[string]probeAttributeValue) map[string]attributeConfig {
+	if len(in) == 0 {
+		return nil
+	}
+
+	out := make(map[string]attributeConfig, len(in))
+	for name, v := range in {
+		out[name] = attributeConfig{
+			Int:    v.Int,
+			Float:  v.Float,
+			String: v.String,
+			Bool:   v.Bool,
+			Unit:   v.Unit,
+		}
+	}
+	return out
+}
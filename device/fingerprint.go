@@ -8,9 +8,13 @@ import (
 	"github.com/hashicorp/nomad/plugins/device"
 )
 
-// doFingerprint is the long-running goroutine that detects device changes
+// doFingerprint is the long-running goroutine that detects device changes.
+// It re-probes on every tick rather than only once so that hotplug
+// additions/removals surfaced by discovery probes (see discover.go) produce
+// an updated FingerprintResponse.
 func (d *GenericDevicePlugin) doFingerprint(ctx context.Context, devices chan *device.FingerprintResponse) {
-	defer close(devices)
+	// devices is closed by the caller (Fingerprint) once doFingerprint and
+	// doHealthCheck have both returned, since both goroutines send on it.
 
 	// Create a timer that will fire immediately for the first detection
 	ticker := time.NewTimer(0)
@@ -23,7 +27,7 @@ func (d *GenericDevicePlugin) doFingerprint(ctx context.Context, devices chan *d
 			ticker.Reset(d.fingerprintPeriod)
 		}
 
-		d.writeFingerprintToChannel(devices)
+		d.writeFingerprintToChannel(ctx, devices)
 	}
 }
 
@@ -35,59 +39,143 @@ type fingerprintedDevice struct {
 	device GenericDevice
 }
 
-// writeFingerprintToChannel collects fingerprint info, partitions devices into
-// device groups, and sends the data over the provided channel.
-func (d *GenericDevicePlugin) writeFingerprintToChannel(devices chan<- *device.FingerprintResponse) {
-	d.deviceLock.Lock()
-	defer d.deviceLock.Unlock()
+// staticConfiguredDevices synthesizes the fixed-count devices the operator
+// declared directly in the plugin's HCL config, along with the health,
+// reserve, and force-exclude configs attached to each one. It does not
+// touch any of the plugin's shared state, so it's safe to call without
+// holding a lock.
+func (d *GenericDevicePlugin) staticConfiguredDevices() ([]*fingerprintedDevice, map[string]*statsProbeConfig, map[string]*healthCheckConfig, map[string]*reserveConfig, map[string]bool) {
+	discoveredDevices := make([]*fingerprintedDevice, 0)
+	statsProbes := make(map[string]*statsProbeConfig)
+	healthProbes := make(map[string]*healthCheckConfig)
+	reserveConfigs := make(map[string]*reserveConfig)
+	excludeIDs := make(map[string]bool)
+
+	for _, configuredDevice := range d.configuredDevices {
+		for _, id := range configuredDevice.ExcludeIDs {
+			excludeIDs[id] = true
+		}
 
-	if len(d.identifiedDevices) == 0 {
-		// "discover" the devices we have configured
-		discoveredDevices := make([]*fingerprintedDevice, 0)
+		count := configuredDevice.Count
+		if count == 0 {
+			count = 1
+		}
+		locality, numaNode, err := resolveLocality(configuredDevice.Locality)
+		if err != nil {
+			d.logger.Warn("failed to resolve device locality", "model", configuredDevice.Model, "error", err)
+		}
 
-		for _, configuredDevice := range d.configuredDevices {
-			count := configuredDevice.Count
-			if count == 0 {
-				count = 1
-			}
-			for deviceIndex := 0; deviceIndex < count; deviceIndex++ {
-				discoveredDevices = append(discoveredDevices, &fingerprintedDevice{
-					ID: fmt.Sprintf("%s/%s/%s/%d", configuredDevice.Type, configuredDevice.Vendor, configuredDevice.Model, deviceIndex),
-					device: GenericDevice{
-						Type:   configuredDevice.Type,
-						Vendor: configuredDevice.Vendor,
-						Model:  configuredDevice.Model,
-					},
-				})
+		attrs := configuredDevice.Attributes
+		if numaNode != nil {
+			merged := make(map[string]attributeConfig, len(configuredDevice.Attributes)+1)
+			for name, attr := range configuredDevice.Attributes {
+				merged[name] = attr
 			}
+			merged[numaNodeAttribute] = attributeConfig{Int: numaNode}
+			attrs = merged
 		}
 
-		d.logger.Info("Found devices", "count", len(discoveredDevices))
-
-		// during fingerprinting, devices are grouped by "device group" in
-		// order to facilitate scheduling
-		// devices in the same device group should have the same
-		// Vendor, Type, and Name ("Model")
-		// Build Fingerprint response with computed groups and send it over the channel
-		deviceListByDeviceName := make(map[string][]*fingerprintedDevice)
-		for _, device := range discoveredDevices {
-			deviceName := device.device.Model
-			deviceListByDeviceName[deviceName] = append(deviceListByDeviceName[deviceName], device)
-			d.identifiedDevices[device.ID] = device.device
+		for deviceIndex := 0; deviceIndex < count; deviceIndex++ {
+			id := fmt.Sprintf("%s/%s/%s/%d", configuredDevice.Type, configuredDevice.Vendor, configuredDevice.Model, deviceIndex)
+			discoveredDevices = append(discoveredDevices, &fingerprintedDevice{
+				ID: id,
+				device: GenericDevice{
+					Type:       configuredDevice.Type,
+					Vendor:     configuredDevice.Vendor,
+					Model:      configuredDevice.Model,
+					Attributes: attrs,
+					Locality:   locality,
+				},
+			})
+			if configuredDevice.StatsProbe != nil {
+				statsProbes[id] = configuredDevice.StatsProbe
+			}
+			if configuredDevice.HealthCheck != nil {
+				healthProbes[id] = configuredDevice.HealthCheck
+			}
+			if configuredDevice.Reserve != nil {
+				reserveConfigs[id] = configuredDevice.Reserve
+			}
 		}
+	}
+
+	return discoveredDevices, statsProbes, healthProbes, reserveConfigs, excludeIDs
+}
+
+// writeFingerprintToChannel merges statically configured devices with
+// whatever the configured discovery probes report, partitions the result
+// into device groups, and sends the data over the provided channel. It
+// re-runs discovery on every call so hotplugged devices are picked up on
+// the next fingerprintPeriod tick.
+func (d *GenericDevicePlugin) writeFingerprintToChannel(ctx context.Context, devices chan<- *device.FingerprintResponse) {
+	discoveredDevices, statsProbes, healthProbes, reserveConfigs, excludeIDs := d.staticConfiguredDevices()
+
+	probed, err := d.runDiscoveryProbes(ctx)
+	if err != nil {
+		d.logger.Error("failed to run device discovery probes", "error", err)
+	} else {
+		discoveredDevices = append(discoveredDevices, probed...)
+	}
+
+	d.configLock.Lock()
+	d.statsProbes = statsProbes
+	d.healthProbes = healthProbes
+	d.reserveConfigs = reserveConfigs
+	d.excludeIDs = excludeIDs
+	d.configLock.Unlock()
 
-		// Build Fingerprint response with computed groups and send it over the channel
-		deviceGroups := make([]*device.DeviceGroup, 0, len(deviceListByDeviceName))
-		for groupName, devices := range deviceListByDeviceName {
-			deviceGroups = append(deviceGroups, deviceGroupFromFingerprintData(groupName, devices))
+	d.deviceLock.Lock()
+	defer d.deviceLock.Unlock()
+
+	identified := make(map[string]GenericDevice, len(discoveredDevices))
+	for _, dev := range discoveredDevices {
+		if excludeIDs[dev.ID] {
+			continue
 		}
+		identified[dev.ID] = dev.device
+	}
+
+	if len(identified) != len(d.identifiedDevices) {
+		d.logger.Info("Found devices", "count", len(identified))
+	}
+	d.identifiedDevices = identified
+
+	devices <- d.buildFingerprintResponse()
+}
 
-		devices <- device.NewFingerprint(deviceGroups...)
+// buildFingerprintResponse groups d.identifiedDevices into device groups and
+// assembles them into a FingerprintResponse, consulting the current health
+// map for each device's Healthy/HealthDesc fields. Callers must hold
+// d.deviceLock.
+func (d *GenericDevicePlugin) buildFingerprintResponse() *device.FingerprintResponse {
+	// during fingerprinting, devices are grouped by "device group" in
+	// order to facilitate scheduling. Nomad assumes devices in the same
+	// device group share Vendor, Type, Name, and Attributes, so the group
+	// key also folds in a hash of the device's attributes: two devices
+	// with the same Model but different Attributes must land in different
+	// groups.
+	deviceListByGroupKey := make(map[string][]*fingerprintedDevice)
+	groupNames := make(map[string]string)
+	for id, dev := range d.identifiedDevices {
+		groupKey, groupName := deviceGroupIdentity(dev)
+		groupNames[groupKey] = groupName
+
+		deviceListByGroupKey[groupKey] = append(deviceListByGroupKey[groupKey], &fingerprintedDevice{ID: id, device: dev})
 	}
+
+	d.healthLock.RLock()
+	defer d.healthLock.RUnlock()
+
+	deviceGroups := make([]*device.DeviceGroup, 0, len(deviceListByGroupKey))
+	for groupKey, devices := range deviceListByGroupKey {
+		deviceGroups = append(deviceGroups, deviceGroupFromFingerprintData(groupNames[groupKey], devices, d.deviceHealth))
+	}
+
+	return device.NewFingerprint(deviceGroups...)
 }
 
 // deviceGroupFromFingerprintData composes deviceGroup from a slice of detected devices
-func deviceGroupFromFingerprintData(groupName string, deviceList []*fingerprintedDevice) *device.DeviceGroup {
+func deviceGroupFromFingerprintData(groupName string, deviceList []*fingerprintedDevice, health map[string]deviceHealthState) *device.DeviceGroup {
 	// deviceGroup without devices makes no sense -> return nil when no devices are provided
 	if len(deviceList) == 0 {
 		return nil
@@ -95,10 +183,17 @@ func deviceGroupFromFingerprintData(groupName string, deviceList []*fingerprinte
 
 	devices := make([]*device.Device, 0, len(deviceList))
 	for _, dev := range deviceList {
+		// devices with no registered health probe are assumed healthy
+		healthy, desc := true, ""
+		if state, ok := health[dev.ID]; ok {
+			healthy, desc = state.Healthy, state.Desc
+		}
+
 		devices = append(devices, &device.Device{
 			ID:         dev.ID,
-			Healthy:    true,
-			HwLocality: nil,
+			Healthy:    healthy,
+			HealthDesc: desc,
+			HwLocality: dev.device.Locality,
 		})
 	}
 
@@ -111,21 +206,10 @@ func deviceGroupFromFingerprintData(groupName string, deviceList []*fingerprinte
 		Name:    groupName,
 		Devices: devices,
 		// The device API assumes that devices with the same DeviceName have the same
-		// attributes like amount of memory, power, bar1memory, etc.
-		// If not, then they'll need to be split into different device groups
-		// with different names.
-		/*
-			Attributes: map[string]*structs.Attribute{
-				"attrA": {
-					Int:  helper.Int64ToPtr(1024),
-					Unit: "MB",
-				},
-				"attrB": {
-					Float: helper.Float64ToPtr(10.5),
-					Unit:  "MW",
-				},
-			},
-		*/
+		// attributes like amount of memory, power, bar1memory, etc. Devices
+		// with differing attributes are split into separate groups upstream
+		// in buildFingerprintResponse, so every device here shares these.
+		Attributes: toStructsAttributes(deviceList[0].device.Attributes),
 	}
 	return deviceGroup
 }
@@ -0,0 +1,190 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/device"
+)
+
+const (
+	// healthCheckTick is how often doHealthCheck wakes up to see which
+	// devices are due for a probe. Individual devices are only actually
+	// probed once their own configured interval has elapsed.
+	healthCheckTick = 5 * time.Second
+
+	// defaultHealthCheckInterval is used for devices that configure a
+	// health check but don't set an interval.
+	defaultHealthCheckInterval = 30 * time.Second
+
+	// dialTimeout bounds how long a tcp health check can block.
+	dialTimeout = 5 * time.Second
+
+	// execProbeTimeout bounds how long an exec health check command may run
+	// before it's killed, so a hung probe script can't block the
+	// health-check goroutine indefinitely.
+	execProbeTimeout = 30 * time.Second
+)
+
+// healthCheckConfig configures how a device's health is determined. Exactly
+// one of Command (exec), Path (file existence), Address (tcp dial), or URL
+// (http GET) is expected to be set, matching Type.
+type healthCheckConfig struct {
+	Type     string        `codec:"type"`
+	Interval time.Duration `codec:"interval"`
+
+	// exec
+	Command string   `codec:"command"`
+	Args    []string `codec:"args"`
+
+	// file
+	Path string `codec:"path"`
+
+	// tcp
+	Address string `codec:"address"`
+
+	// http
+	URL          string `codec:"url"`
+	ExpectStatus int    `codec:"expect_status"`
+}
+
+// deviceHealthState is the last-known health of a single device.
+type deviceHealthState struct {
+	Healthy bool
+	Desc    string
+}
+
+// doHealthCheck is the long-running goroutine that periodically runs each
+// fingerprinted device's configured health probe, and publishes a fresh
+// FingerprintResponse whenever a device's health changes.
+func (d *GenericDevicePlugin) doHealthCheck(ctx context.Context, devices chan<- *device.FingerprintResponse) {
+	ticker := time.NewTicker(healthCheckTick)
+	defer ticker.Stop()
+
+	lastChecked := make(map[string]time.Time)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if d.runDueHealthProbes(ctx, lastChecked) {
+			d.deviceLock.Lock()
+			resp := d.buildFingerprintResponse()
+			d.deviceLock.Unlock()
+
+			select {
+			case devices <- resp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// runDueHealthProbes runs the health probe for every device whose interval
+// has elapsed since lastChecked, updates d.deviceHealth, and reports whether
+// any device's health changed.
+func (d *GenericDevicePlugin) runDueHealthProbes(ctx context.Context, lastChecked map[string]time.Time) bool {
+	d.configLock.RLock()
+	probes := make(map[string]*healthCheckConfig, len(d.healthProbes))
+	for id, probe := range d.healthProbes {
+		probes[id] = probe
+	}
+	d.configLock.RUnlock()
+
+	now := time.Now()
+	changed := false
+
+	for id, probe := range probes {
+		interval := probe.Interval
+		if interval == 0 {
+			interval = defaultHealthCheckInterval
+		}
+		if now.Sub(lastChecked[id]) < interval {
+			continue
+		}
+		lastChecked[id] = now
+
+		healthy, desc := runHealthProbe(ctx, probe)
+
+		d.healthLock.Lock()
+		prev, known := d.deviceHealth[id]
+		if !known || prev.Healthy != healthy || prev.Desc != desc {
+			changed = true
+		}
+		d.deviceHealth[id] = deviceHealthState{Healthy: healthy, Desc: desc}
+		d.healthLock.Unlock()
+	}
+
+	return changed
+}
+
+// runHealthProbe executes a single device's configured health probe and
+// reports whether it's healthy, along with a human-readable description.
+func runHealthProbe(ctx context.Context, probe *healthCheckConfig) (bool, string) {
+	switch probe.Type {
+	case "exec":
+		return execHealthProbe(ctx, probe)
+	case "file":
+		return fileHealthProbe(probe)
+	case "tcp":
+		return tcpHealthProbe(probe)
+	case "http":
+		return httpHealthProbe(probe)
+	default:
+		return false, fmt.Sprintf("unknown health check type %q", probe.Type)
+	}
+}
+
+func execHealthProbe(ctx context.Context, probe *healthCheckConfig) (bool, string) {
+	ctx, cancel := context.WithTimeout(ctx, execProbeTimeout)
+	defer cancel()
+
+	if err := exec.CommandContext(ctx, probe.Command, probe.Args...).Run(); err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+func fileHealthProbe(probe *healthCheckConfig) (bool, string) {
+	if _, err := os.Stat(probe.Path); err != nil {
+		return false, err.Error()
+	}
+	return true, ""
+}
+
+func tcpHealthProbe(probe *healthCheckConfig) (bool, string) {
+	conn, err := net.DialTimeout("tcp", probe.Address, dialTimeout)
+	if err != nil {
+		return false, err.Error()
+	}
+	conn.Close()
+	return true, ""
+}
+
+func httpHealthProbe(probe *healthCheckConfig) (bool, string) {
+	client := http.Client{Timeout: dialTimeout}
+
+	resp, err := client.Get(probe.URL)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer resp.Body.Close()
+
+	expect := probe.ExpectStatus
+	if expect == 0 {
+		expect = http.StatusOK
+	}
+	if resp.StatusCode != expect {
+		return false, fmt.Sprintf("expected status %d, got %d", expect, resp.StatusCode)
+	}
+	return true, ""
+}
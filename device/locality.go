@@ -0,0 +1,79 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/nomad/plugins/device"
+)
+
+// locality attribute key under which a resolved NUMA node is exposed. The
+// real device.DeviceLocality only carries a PciBusID, so the NUMA node is
+// surfaced as a regular device attribute instead, letting the scheduler
+// constrain/affinitize on it the same way it would any other attribute.
+const numaNodeAttribute = "platform.numa_node"
+
+// localityConfig lets an operator declare a device's NUMA/PCI locality
+// directly, or point at its PCI bus ID so the NUMA node can be resolved
+// from sysfs.
+type localityConfig struct {
+	PCIBusID string `codec:"pci_bus_id"`
+	NumaNode *int   `codec:"numa_node"`
+}
+
+// resolveLocality turns a device's locality config into the
+// device.DeviceLocality Nomad's scheduler uses to co-locate tasks with the
+// same PCI device, plus the resolved NUMA node (if any) to attach as an
+// attribute. If NumaNode isn't declared explicitly, it's resolved from
+// /sys/bus/pci/devices/<pci_bus_id>/numa_node.
+func resolveLocality(cfg *localityConfig) (*device.DeviceLocality, *int64, error) {
+	if cfg == nil {
+		return nil, nil, nil
+	}
+
+	var numaNode *int64
+	switch {
+	case cfg.NumaNode != nil:
+		v := int64(*cfg.NumaNode)
+		numaNode = &v
+	case cfg.PCIBusID != "":
+		v, err := numaNodeForPCIBusID(cfg.PCIBusID)
+		if err != nil {
+			return nil, nil, err
+		}
+		numaNode = v
+	}
+
+	var locality *device.DeviceLocality
+	if cfg.PCIBusID != "" {
+		locality = &device.DeviceLocality{PciBusID: cfg.PCIBusID}
+	}
+
+	return locality, numaNode, nil
+}
+
+// numaNodeForPCIBusID reads the NUMA node a PCI device is attached to from
+// sysfs. A node of -1 means sysfs reports no NUMA affinity (common on
+// single-socket hosts/VMs), which is distinct from being pinned to node 0,
+// so it's returned as nil rather than coerced to 0.
+func numaNodeForPCIBusID(pciBusID string) (*int64, error) {
+	path := fmt.Sprintf("/sys/bus/pci/devices/%s/numa_node", pciBusID)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading numa_node for pci device %q: %w", pciBusID, err)
+	}
+
+	numaNode, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("parsing numa_node for pci device %q: %w", pciBusID, err)
+	}
+	if numaNode < 0 {
+		return nil, nil
+	}
+
+	v := int64(numaNode)
+	return &v, nil
+}
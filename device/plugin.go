@@ -0,0 +1,184 @@
+package device
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/nomad/plugins/device"
+)
+
+const (
+	// defaultFingerprintPeriod is how often the plugin re-runs fingerprinting
+	// when the operator doesn't configure one explicitly
+	defaultFingerprintPeriod = 1 * time.Minute
+)
+
+// configuredDevice is the HCL representation of a single device the operator
+// wants this plugin to expose to Nomad.
+type configuredDevice struct {
+	Vendor string `codec:"vendor"`
+	Type   string `codec:"type"`
+	Model  string `codec:"model"`
+
+	// Count is the number of identical devices to synthesize for this entry.
+	// Defaults to 1 when unset.
+	Count int `codec:"count"`
+
+	// StatsProbe configures how per-device stats are collected for this
+	// entry. Nil disables stats collection for these devices.
+	StatsProbe *statsProbeConfig `codec:"stats_probe"`
+
+	// HealthCheck configures how device health is determined for this
+	// entry. Nil means devices are always reported healthy.
+	HealthCheck *healthCheckConfig `codec:"health_check"`
+
+	// Attributes are typed, operator-declared attributes attached to
+	// every device synthesized from this entry.
+	Attributes map[string]attributeConfig `codec:"attributes"`
+
+	// Locality, if set, lets Nomad's scheduler co-locate tasks on the same
+	// NUMA node as this entry's devices.
+	Locality *localityConfig `codec:"locality"`
+
+	// Reserve configures what a task gets when it reserves one of this
+	// entry's devices: mounts, device nodes, and templated env vars.
+	Reserve *reserveConfig `codec:"reserve"`
+
+	// ExcludeIDs force-excludes specific device IDs (static or discovered)
+	// from fingerprinting regardless of what this entry would otherwise
+	// produce.
+	ExcludeIDs []string `codec:"exclude_ids"`
+}
+
+// statsProbeConfig configures an external command that is invoked per
+// device ID to collect its current stats. The command is expected to print
+// a single JSON object (see probeStatsOutput) to stdout.
+type statsProbeConfig struct {
+	Command string   `codec:"command"`
+	Args    []string `codec:"args"`
+}
+
+// GenericDevice is what we "discover" and transform into device.Device objects.
+//
+// plugin implementations will likely have a native struct provided by the corresonding SDK
+type GenericDevice struct {
+	Vendor string
+	Type   string
+	Model  string
+
+	// Attributes are operator-declared, per-device-group attributes (e.g.
+	// memory size) that Nomad can schedule constraints/affinities against.
+	Attributes map[string]attributeConfig
+
+	// Locality is the device's resolved NUMA/PCI topology, if configured.
+	Locality *device.DeviceLocality
+}
+
+// attributeConfig is the HCL representation of a single typed device
+// attribute. Exactly one of Int, Float, String, or Bool is expected to be
+// set.
+type attributeConfig struct {
+	Int    *int64   `codec:"int"`
+	Float  *float64 `codec:"float"`
+	String *string  `codec:"string"`
+	Bool   *bool    `codec:"bool"`
+	Unit   string   `codec:"unit"`
+}
+
+// GenericDevicePlugin implements the device.DevicePlugin interface for
+// operator-declared devices that have no dedicated Nomad device plugin of
+// their own.
+type GenericDevicePlugin struct {
+	logger hclog.Logger
+
+	configuredDevices []configuredDevice
+
+	// discoveryCommands are executables invoked on every fingerprint tick
+	// whose JSON stdout describes additional devices to merge in; see
+	// discover.go.
+	discoveryCommands []string
+
+	fingerprintPeriod time.Duration
+
+	deviceLock        sync.RWMutex
+	identifiedDevices map[string]GenericDevice
+
+	// configLock guards excludeIDs, statsProbes, healthProbes, and
+	// reserveConfigs, which are rewritten on every fingerprint tick
+	// (outside of deviceLock, since discovery probes can be slow) while
+	// Reserve and the stats and health-check goroutines read them
+	// concurrently.
+	configLock sync.RWMutex
+
+	// excludeIDs lets an operator force a device ID out of fingerprinting
+	// regardless of what it was configured or discovered as, populated
+	// from each configuredDevice's ExcludeIDs. Guarded by configLock.
+	excludeIDs map[string]bool
+
+	// statsProbes holds each fingerprinted device's configured stats
+	// probe, keyed by device ID. Guarded by configLock.
+	statsProbes map[string]*statsProbeConfig
+
+	// healthProbes holds each fingerprinted device's configured health
+	// probe, keyed by device ID. Guarded by configLock.
+	healthProbes map[string]*healthCheckConfig
+
+	// reserveConfigs holds each fingerprinted device's configured mounts,
+	// device specs, and env var templates, keyed by device ID. Guarded by
+	// configLock.
+	reserveConfigs map[string]*reserveConfig
+
+	healthLock   sync.RWMutex
+	deviceHealth map[string]deviceHealthState
+}
+
+// NewPlugin returns a device plugin, used primarily by the main wrapper
+func NewPlugin(log hclog.Logger) *GenericDevicePlugin {
+	return &GenericDevicePlugin{
+		logger:            log,
+		fingerprintPeriod: defaultFingerprintPeriod,
+		identifiedDevices: make(map[string]GenericDevice),
+		excludeIDs:        make(map[string]bool),
+		statsProbes:       make(map[string]*statsProbeConfig),
+		healthProbes:      make(map[string]*healthCheckConfig),
+		reserveConfigs:    make(map[string]*reserveConfig),
+		deviceHealth:      make(map[string]deviceHealthState),
+	}
+}
+
+// Fingerprint streams detected devices and their health to Nomad, as
+// required by the device.DevicePlugin interface.
+func (d *GenericDevicePlugin) Fingerprint(ctx context.Context) (<-chan *device.FingerprintResponse, error) {
+	outCh := make(chan *device.FingerprintResponse)
+
+	// doFingerprint and doHealthCheck both send on outCh independently, so
+	// neither can own closing it: closing after only one returns would race
+	// the other's send against the close, panicking with "send on closed
+	// channel". A WaitGroup defers the close until both have exited.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		d.doFingerprint(ctx, outCh)
+	}()
+	go func() {
+		defer wg.Done()
+		d.doHealthCheck(ctx, outCh)
+	}()
+	go func() {
+		wg.Wait()
+		close(outCh)
+	}()
+
+	return outCh, nil
+}
+
+// Stats streams periodic resource usage stats for every fingerprinted
+// device, as required by the device.DevicePlugin interface.
+func (d *GenericDevicePlugin) Stats(ctx context.Context, interval time.Duration) (<-chan *device.StatsResponse, error) {
+	outCh := make(chan *device.StatsResponse)
+	go d.doStats(ctx, outCh, interval)
+	return outCh, nil
+}
@@ -0,0 +1,141 @@
+package device
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/nomad/plugins/device"
+)
+
+// envJoinSeparator joins the per-device values of an env var across
+// multiple reserved devices, matching the NVIDIA_VISIBLE_DEVICES-style
+// convention of a single comma-separated value listing every device.
+const envJoinSeparator = ","
+
+// reserveConfig declares what a task gets when it reserves a device: mounts
+// to bind in, device nodes to expose, and env vars to set.
+type reserveConfig struct {
+	Mounts  []mountConfig      `codec:"mount"`
+	Devices []deviceSpecConfig `codec:"device"`
+
+	// Env values are text/template strings expanded per reserved device
+	// with {{.ID}}, {{.Index}}, {{.Vendor}}, {{.Type}}, and {{.Model}}. When
+	// a task reserves multiple devices from this entry, each device's
+	// rendered value is joined with envJoinSeparator rather than the last
+	// one winning, so e.g. an NVIDIA_VISIBLE_DEVICES-style var lists every
+	// reserved device.
+	Env map[string]string `codec:"env"`
+}
+
+// mountConfig declares a host path to bind into the task.
+type mountConfig struct {
+	HostPath string `codec:"host_path"`
+	TaskPath string `codec:"task_path"`
+	ReadOnly bool   `codec:"read_only"`
+}
+
+// deviceSpecConfig declares a device node to expose inside the task.
+type deviceSpecConfig struct {
+	HostPath    string `codec:"host_path"`
+	TaskPath    string `codec:"task_path"`
+	CgroupPerms string `codec:"cgroup_perms"`
+}
+
+// envTemplateData is the context available to a reserveConfig.Env template.
+type envTemplateData struct {
+	ID     string
+	Index  int
+	Vendor string
+	Type   string
+	Model  string
+}
+
+// Reserve builds the mounts, device specs, and env vars a task needs to use
+// the given devices, as required by the device.DevicePlugin interface.
+func (d *GenericDevicePlugin) Reserve(deviceIDs []string) (*device.ContainerReservation, error) {
+	d.deviceLock.RLock()
+	defer d.deviceLock.RUnlock()
+
+	d.configLock.RLock()
+	defer d.configLock.RUnlock()
+
+	reservation := &device.ContainerReservation{
+		Envs:    make(map[string]string),
+		Mounts:  make([]*device.Mount, 0),
+		Devices: make([]*device.DeviceSpec, 0),
+	}
+
+	// envValues accumulates each env var's rendered value per reserved
+	// device, in reservation order, so reserving several devices of the
+	// same entry joins their values (e.g. "NVIDIA_VISIBLE_DEVICES=0,1")
+	// instead of the last device silently clobbering the rest.
+	envValues := make(map[string][]string)
+
+	for index, id := range deviceIDs {
+		dev, ok := d.identifiedDevices[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown device ID %q", id)
+		}
+
+		cfg := d.reserveConfigs[id]
+		if cfg == nil {
+			continue
+		}
+
+		data := envTemplateData{
+			ID:     id,
+			Index:  index,
+			Vendor: dev.Vendor,
+			Type:   dev.Type,
+			Model:  dev.Model,
+		}
+
+		for name, tmplStr := range cfg.Env {
+			value, err := renderEnvTemplate(tmplStr, data)
+			if err != nil {
+				return nil, fmt.Errorf("rendering env var %q for device %q: %w", name, id, err)
+			}
+			envValues[name] = append(envValues[name], value)
+		}
+
+		for _, m := range cfg.Mounts {
+			reservation.Mounts = append(reservation.Mounts, &device.Mount{
+				HostPath: m.HostPath,
+				TaskPath: m.TaskPath,
+				ReadOnly: m.ReadOnly,
+			})
+		}
+
+		for _, ds := range cfg.Devices {
+			reservation.Devices = append(reservation.Devices, &device.DeviceSpec{
+				TaskPath:    ds.TaskPath,
+				HostPath:    ds.HostPath,
+				CgroupPerms: ds.CgroupPerms,
+			})
+		}
+	}
+
+	for name, values := range envValues {
+		reservation.Envs[name] = strings.Join(values, envJoinSeparator)
+	}
+
+	return reservation, nil
+}
+
+// renderEnvTemplate expands an env var template for a single reserved
+// device.
+func renderEnvTemplate(tmplStr string, data envTemplateData) (string, error) {
+	tmpl, err := template.New("env").Parse(tmplStr)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
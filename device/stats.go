@@ -0,0 +1,191 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/hashicorp/nomad/plugins/device"
+	"github.com/hashicorp/nomad/plugins/shared/structs"
+)
+
+const (
+	// statsBackoffBaseline is the baseline delay after a failed stats
+	// collection attempt before the next one is retried.
+	statsBackoffBaseline = 5 * time.Second
+
+	// statsBackoffLimit is the maximum delay between stats collection
+	// retries once backoff has grown from repeated failures.
+	statsBackoffLimit = 30 * time.Minute
+
+	// statsProbeTimeout bounds how long a single stats probe command may
+	// run before it's killed. Stats() only binds its context to the
+	// long-lived stream, not a per-call deadline, so a hung probe script
+	// would otherwise block doStats (and every other device's stats,
+	// since collectStats aborts the whole batch on first error)
+	// indefinitely, and the exponential backoff above would never engage.
+	statsProbeTimeout = 30 * time.Second
+)
+
+// statMetric is a single named measurement reported by a device's stats
+// probe command, as JSON on stdout.
+type statMetric struct {
+	Name    string  `json:"name"`
+	Value   float64 `json:"value"`
+	Unit    string  `json:"unit"`
+	Desc    string  `json:"desc"`
+	Counter bool    `json:"counter"`
+}
+
+// probeStatsOutput is the expected shape of a stats probe's stdout: the
+// metrics it collected for the device it was invoked for.
+type probeStatsOutput struct {
+	Metrics []statMetric `json:"metrics"`
+}
+
+// doStats is the long-running goroutine that collects per-device stats by
+// shelling out to each device's configured probe command. Collection
+// failures back off exponentially, starting at statsBackoffBaseline and
+// capping at statsBackoffLimit; the backoff resets after the first
+// successful collection.
+func (d *GenericDevicePlugin) doStats(ctx context.Context, stats chan *device.StatsResponse, interval time.Duration) {
+	defer close(stats)
+
+	backoff := time.Duration(0)
+	timer := time.NewTimer(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		resp, err := d.collectStats(ctx)
+		if err != nil {
+			if backoff == 0 {
+				backoff = statsBackoffBaseline
+			} else {
+				backoff *= 2
+				if backoff > statsBackoffLimit {
+					backoff = statsBackoffLimit
+				}
+			}
+			d.logger.Error("failed to collect device stats", "error", err, "retry", backoff)
+			timer.Reset(backoff)
+			continue
+		}
+
+		backoff = 0
+		timer.Reset(interval)
+
+		select {
+		case stats <- resp:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// collectStats runs the stats probe for every fingerprinted device that has
+// one configured and assembles the results into a single StatsResponse,
+// grouped the same way fingerprinting groups devices (see
+// deviceGroupIdentity) so DeviceGroupStats line up with what Nomad actually
+// scheduled against.
+func (d *GenericDevicePlugin) collectStats(ctx context.Context) (*device.StatsResponse, error) {
+	d.deviceLock.RLock()
+	identifiedDevices := make(map[string]GenericDevice, len(d.identifiedDevices))
+	for id, dev := range d.identifiedDevices {
+		identifiedDevices[id] = dev
+	}
+	d.deviceLock.RUnlock()
+
+	d.configLock.RLock()
+	statsProbes := make(map[string]*statsProbeConfig, len(d.statsProbes))
+	for id, probe := range d.statsProbes {
+		statsProbes[id] = probe
+	}
+	d.configLock.RUnlock()
+
+	groupStats := make(map[string]*device.DeviceGroupStats)
+
+	for id, dev := range identifiedDevices {
+		probe := statsProbes[id]
+		if probe == nil {
+			continue
+		}
+
+		groupKey, groupName := deviceGroupIdentity(dev)
+		group, ok := groupStats[groupKey]
+		if !ok {
+			group = &device.DeviceGroupStats{
+				Vendor:        dev.Vendor,
+				Type:          dev.Type,
+				Name:          groupName,
+				InstanceStats: make(map[string]*device.DeviceStats),
+			}
+			groupStats[groupKey] = group
+		}
+
+		deviceStats, err := runStatsProbe(ctx, probe, id)
+		if err != nil {
+			return nil, fmt.Errorf("stats probe for device %q failed: %w", id, err)
+		}
+
+		group.InstanceStats[id] = deviceStats
+	}
+
+	groups := make([]*device.DeviceGroupStats, 0, len(groupStats))
+	for _, group := range groupStats {
+		groups = append(groups, group)
+	}
+
+	return &device.StatsResponse{Groups: groups}, nil
+}
+
+// runStatsProbe executes a single device's configured probe command and
+// maps its JSON stdout into a device.DeviceStats entry.
+func runStatsProbe(ctx context.Context, probe *statsProbeConfig, deviceID string) (*device.DeviceStats, error) {
+	ctx, cancel := context.WithTimeout(ctx, statsProbeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, probe.Command, append(probe.Args, deviceID)...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, stderr.String())
+	}
+
+	var out probeStatsOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("decoding probe output: %w", err)
+	}
+
+	attrs := make(map[string]*structs.StatValue, len(out.Metrics))
+	for _, m := range out.Metrics {
+		value := m.Value
+		sv := &structs.StatValue{
+			Unit: m.Unit,
+			Desc: m.Desc,
+		}
+		if m.Counter {
+			intVal := int64(value)
+			sv.IntNumeratorVal = &intVal
+		} else {
+			sv.FloatNumeratorVal = &value
+		}
+		attrs[m.Name] = sv
+	}
+
+	return &device.DeviceStats{
+		Stats: &structs.StatObject{
+			Attributes: attrs,
+		},
+		Timestamp: time.Now(),
+	}, nil
+}